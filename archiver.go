@@ -0,0 +1,9 @@
+package main
+
+import "net/http"
+
+// Archiver is notified of every page the crawler successfully fetches, and
+// can persist it however it likes (e.g. as a WARC record).
+type Archiver interface {
+	Record(req *http.Request, resp *http.Response, body []byte) error
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -12,19 +16,96 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// Crawler is a HTML crawler with configurable maximum depth and rate limit.
-// Instances of Crawler are not safe for concurrent use.
+// cssURLRegexp matches the URL inside a CSS url(...) reference, as found in
+// @import rules and property values such as background: url(...).
+var cssURLRegexp = regexp.MustCompile(`(?:@import|:).*?url\(["']?([^'"\)]+)["']?\)`)
+
+// defaultConcurrency is the number of crawl workers started when
+// NewCrawler is called with a non-positive concurrency.
+const defaultConcurrency = 8
+
+// Crawler is a HTML crawler with a configurable rate limit that follows
+// only links allowed by its scopes, using a pool of workers to crawl pages
+// concurrently.
 type Crawler struct {
-	visited  SchemelessURLSet // to avoid circular crawling
-	client   *http.Client
-	root     *url.URL
-	maxDepth int
-	limiter  *rate.Limiter
+	visited      *LinkSet // to avoid circular crawling
+	client       *http.Client
+	root         *url.URL
+	scopes       []Scope
+	limiter      *rate.Limiter
+	concurrency  int
+	normalize    func(*url.URL) *url.URL
+	archiver     Archiver
+	ignoreRobots bool
+	robotsMu     sync.Mutex
+	robotsCache  map[string]*robotsPolicy
+	robotsOnce   map[string]*sync.Once
+	state        *StateStore
+}
+
+// Option configures optional behavior on the Crawler returned by
+// NewCrawler. See the With* functions below for the available options.
+type Option func(*Crawler)
+
+// WithScopes overrides the default scope — staying within maxDepth levels
+// and on the entrypoint's host — with scopes. A link found on a page is
+// only followed if every scope in scopes allows it.
+func WithScopes(scopes []Scope) Option {
+	return func(c *Crawler) {
+		c.scopes = scopes
+	}
+}
+
+// WithConcurrency overrides defaultConcurrency as the number of workers
+// crawling pages concurrently.
+func WithConcurrency(concurrency int) Option {
+	return func(c *Crawler) {
+		c.concurrency = concurrency
+	}
+}
+
+// WithNormalize overrides DefaultNormalize as the function every URL is
+// passed through before it is stored or crawled.
+func WithNormalize(normalize func(*url.URL) *url.URL) Option {
+	return func(c *Crawler) {
+		c.normalize = normalize
+	}
 }
 
-// NewCrawler returns a crawler ready to crawl the page at the specified link,
-// limited to the specified maximum depth and outgoing request rate.
-func NewCrawler(link string, maxDepth int, maxReqsPerSecond float64) (*Crawler, error) {
+// WithArchiver hands every page the crawler successfully fetches to
+// archiver.
+func WithArchiver(archiver Archiver) Option {
+	return func(c *Crawler) {
+		c.archiver = archiver
+	}
+}
+
+// WithIgnoreRobots disables robots.txt checks, so every in-scope link is
+// crawled regardless of what the target host's robots.txt says.
+func WithIgnoreRobots(ignoreRobots bool) Option {
+	return func(c *Crawler) {
+		c.ignoreRobots = ignoreRobots
+	}
+}
+
+// WithState persists every discovered URL and tree edge to state. If state
+// already holds data from a previous run, Run resumes from it instead of
+// starting over at the entrypoint passed to NewCrawler.
+func WithState(state *StateStore) Option {
+	return func(c *Crawler) {
+		c.state = state
+	}
+}
+
+// NewCrawler returns a crawler ready to crawl the page at the specified
+// link, at the specified maximum outgoing request rate, staying within
+// maxDepth levels by default. Behavior beyond these required parameters is
+// configured via opts; see the With* functions for what's available. With
+// no options, a crawler stays on the entrypoint's host, runs
+// defaultConcurrency workers, normalizes URLs with DefaultNormalize,
+// doesn't archive pages, respects robots.txt, and keeps no resumable
+// state.
+func NewCrawler(link string, maxDepth int, maxReqsPerSecond float64, opts ...Option) (*Crawler, error) {
 	root, err := url.Parse(link)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to parse %s", link)
@@ -38,33 +119,184 @@ func NewCrawler(link string, maxDepth int, maxReqsPerSecond float64) (*Crawler,
 		root.Path = "/"
 	}
 
-	return &Crawler{
-		visited: SchemelessURLSet{},
+	c := &Crawler{
+		visited: NewLinkSet(),
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		root:     root,
-		maxDepth: maxDepth,
 		// the following line creates a limiter with burst = 1.
 		// burst of 1 is fine; we never use limiter.*N methods.
-		limiter: rate.NewLimiter(rate.Limit(maxReqsPerSecond), 1),
-	}, nil
+		limiter:     rate.NewLimiter(rate.Limit(maxReqsPerSecond), 1),
+		robotsCache: map[string]*robotsPolicy{},
+		robotsOnce:  map[string]*sync.Once{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if len(c.scopes) == 0 {
+		c.scopes = []Scope{DepthScope(maxDepth), HostScope{root.Hostname()}}
+	}
+
+	if c.concurrency <= 0 {
+		c.concurrency = defaultConcurrency
+	}
+
+	if c.normalize == nil {
+		c.normalize = DefaultNormalize
+	}
+
+	c.root = c.normalize(root)
+
+	return c, nil
 }
 
-// Run returns link tree found on the page specified by c.root (= the link passed
-// to NewCrawler). Run recursively walks the page tree, following only links with
-// the same hostname as the link passed into NewCrawler, to the maximum depth and
-// with the maximum outgoing request rate configured in NewCrawler.
+// Run returns the link tree found on the page specified by c.root (= the
+// link passed to NewCrawler). Run fans crawling of the page tree out across
+// c.concurrency workers, following only links allowed by c.scopes, with the
+// maximum outgoing request rate configured in NewCrawler. If c.state holds
+// data from a previous, interrupted run, Run resumes from it instead of
+// starting over at c.root.
 func (c *Crawler) Run() (URLTree, error) {
-	c.visited.EnsureContains(c.root)
-	return c.crawl(c.root, 0)
+	q := newQueue()
+	var pending sync.WaitGroup
+	registry := newTreeRegistry()
+
+	var root *url.URL
+	if c.state != nil && len(c.state.Resumed.URLs) > 0 {
+		root = c.resume(q, &pending, registry)
+	} else {
+		root = c.root
+		c.visited.EnsureContains(root)
+		if c.robotsAllow(root) {
+			if c.state != nil {
+				if err := c.state.RecordPending(root, 0); err != nil {
+					return nil, errors.Wrapf(err, "error recording %s as pending", root)
+				}
+			}
+			pending.Add(1)
+			q.in <- queueItem{url: root, depth: 0}
+		}
+	}
+
+	workerResults := make([]<-chan result, c.concurrency)
+	var workers sync.WaitGroup
+	workers.Add(c.concurrency)
+	for i := 0; i < c.concurrency; i++ {
+		ch := make(chan result)
+		workerResults[i] = ch
+		go func(ch chan<- result) {
+			defer workers.Done()
+			defer close(ch)
+			c.work(q, ch, &pending)
+		}(ch)
+	}
+
+	go func() {
+		pending.Wait()
+		close(q.in)
+	}()
+
+	rootTree := registry.get(root)
+
+	var firstErr error
+	for res := range funnel(workerResults...) {
+		if res.err != nil {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+
+		tree := registry.get(res.link)
+		for child := range res.tree {
+			tree[child] = registry.get(child)
+		}
+	}
+
+	workers.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return rootTree, nil
 }
 
-// crawl returns the link tree found on the page specified by pageURL. crawl recursively
-// walks the page tree, following only links with the same hostname as c.root, and only
-// to the maximum depth configured in c.maxDepth. The number of outgoing requests is
-// limited by c.limiter.
-func (c *Crawler) crawl(pageURL *url.URL, depth int) (URLTree, error) {
+// resume seeds q with every URL c.state.Resumed marks "pending" and still
+// in scope at its recorded depth, marks every URL it recovers as visited,
+// and replays every recovered tree edge into registry. It returns the
+// *url.URL to use as the crawl's tree root, re-parsed from c.root so that
+// it is the same pointer used by any recovered edge pointing at it.
+func (c *Crawler) resume(q *queue, pending *sync.WaitGroup, registry *treeRegistry) *url.URL {
+	interned := map[string]*url.URL{}
+	intern := func(s string) *url.URL {
+		if u, ok := interned[s]; ok {
+			return u
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			// keep the raw string around as a path so a corrupt record
+			// doesn't crash resume; it will just show up oddly in the tree
+			u = &url.URL{Path: s}
+		}
+		interned[s] = u
+		return u
+	}
+
+	for _, edge := range c.state.Resumed.Edges {
+		parent := intern(edge.Parent)
+		child := intern(edge.Child)
+		c.visited.EnsureContains(child)
+		registry.get(parent)[child] = registry.get(child)
+	}
+
+	for urlStr, info := range c.state.Resumed.URLs {
+		u := intern(urlStr)
+		c.visited.EnsureContains(u)
+
+		if info.Status == "pending" && c.inScope(u, info.Depth) {
+			pending.Add(1)
+			q.in <- queueItem{url: u, depth: info.Depth}
+		}
+	}
+
+	return intern(c.root.String())
+}
+
+// work pulls queue items off q.out until it is closed, crawling each one
+// and sending its result on results, marking the item done on pending
+// afterwards.
+func (c *Crawler) work(q *queue, results chan<- result, pending *sync.WaitGroup) {
+	for it := range q.out {
+		tree, err := c.crawlOne(it.url, it.depth, q, pending)
+
+		if c.state != nil {
+			var stateErr error
+			if err != nil {
+				stateErr = c.state.RecordError(it.url)
+			} else {
+				stateErr = c.state.RecordDone(it.url)
+			}
+			// don't let a secondary state-write failure mask a real
+			// crawling error, but otherwise surface it: a lost state
+			// write would make a later resume silently incomplete
+			if stateErr != nil && err == nil {
+				err = errors.Wrapf(stateErr, "error recording state for %s", it.url)
+			}
+		}
+
+		results <- result{link: it.url, tree: tree, err: err}
+		pending.Done()
+	}
+}
+
+// crawlOne fetches pageURL, extracts its links, enqueues any new in-scope
+// primary links found on it for crawling by another worker, and returns the
+// (not yet nested) tree of links found directly on pageURL. The number of
+// outgoing requests is limited by c.limiter.
+func (c *Crawler) crawlOne(pageURL *url.URL, depth int, q *queue, pending *sync.WaitGroup) (URLTree, error) {
 	// fmt.Println("crawling", pageURL)
 
 	// make sure we stay nice
@@ -73,25 +305,43 @@ func (c *Crawler) crawl(pageURL *url.URL, depth int) (URLTree, error) {
 		return nil, errors.Wrapf(err, "error while waiting to be allowed to crawl %s", pageURL)
 	}
 
-	page, err := c.fetch(pageURL)
+	req, resp, err := c.fetch(pageURL)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error fetching %s", pageURL)
 	}
 
-	hrefs, err := c.uniqueLinksInPage(page, pageURL)
-	page.Close()
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", pageURL)
+	}
+
+	if c.archiver != nil {
+		if err := c.archiver.Record(req, resp, body); err != nil {
+			return nil, errors.Wrapf(err, "error archiving %s", pageURL)
+		}
+	}
+
+	links, err := c.uniqueLinksInPage(bytes.NewReader(body), pageURL)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error finding links on %s", pageURL)
 	}
 
 	tree := URLTree{}
 
-	for _, href := range hrefs {
+	for _, link := range links {
+		href := link.URL
 		// fmt.Println(pageURL, "links to", href)
 
 		// store url in this page's tree, with no sub tree for now
 		tree[href] = nil
 
+		if c.state != nil {
+			if err := c.state.RecordEdge(pageURL, href); err != nil {
+				return nil, errors.Wrapf(err, "error recording edge %s -> %s", pageURL, href)
+			}
+		}
+
 		// mark this link as visited, if not already
 		if c.visited.EnsureContains(href) {
 			// don't crawl if it was already marked visited
@@ -99,28 +349,50 @@ func (c *Crawler) crawl(pageURL *url.URL, depth int) (URLTree, error) {
 			continue
 		}
 
-		// don't crawl too deep, or on the wrong host
-		if depth+1 >= c.maxDepth || href.Hostname() != c.root.Hostname() {
+		// only follow primary (<a href>) links; related resources are
+		// recorded in the tree but never crawled themselves
+		if link.Tag != TagPrimary {
 			continue
 		}
 
-		// crawl the linked page and return its sub tree
-		subTree, err := c.crawl(href, depth+1)
-		if err != nil {
-			return nil, err
+		// don't crawl links outside of scope
+		if !c.inScope(href, depth+1) {
+			continue
 		}
 
-		// store sub tree of links found on linked page in tree
-		tree[href] = subTree
+		// don't crawl paths disallowed by the target host's robots.txt
+		if !c.robotsAllow(href) {
+			continue
+		}
+
+		// enqueue the linked page to be crawled by a worker
+		if c.state != nil {
+			if err := c.state.RecordPending(href, depth+1); err != nil {
+				return nil, errors.Wrapf(err, "error recording %s as pending", href)
+			}
+		}
+		pending.Add(1)
+		q.in <- queueItem{url: href, depth: depth + 1}
 	}
 
 	return tree, nil
 }
 
-// parseToAbsURL parses the provided string as URL and if necessary
-// resolves it to an absolute URL using c.root as base. parseToAbsURL
-// also ensures the returned URL has a non-empty path.
-func parseToAbsURL(href string, base *url.URL) (*url.URL, error) {
+// inScope reports whether every one of c.scopes allows following u, found
+// at the given depth.
+func (c *Crawler) inScope(u *url.URL, depth int) bool {
+	for _, scope := range c.scopes {
+		if !scope.Check(u, depth) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseToAbsURL parses the provided string as URL and if necessary resolves
+// it to an absolute URL using base. parseToAbsURL also ensures the returned
+// URL has a non-empty path, and runs it through c.normalize.
+func (c *Crawler) parseToAbsURL(href string, base *url.URL) (*url.URL, error) {
 	parsed, err := url.Parse(href)
 	if err != nil {
 		return nil, err
@@ -130,68 +402,148 @@ func parseToAbsURL(href string, base *url.URL) (*url.URL, error) {
 		parsed.Path = "/"
 	}
 
-	return base.ResolveReference(parsed), nil
+	return c.normalize(base.ResolveReference(parsed)), nil
 }
 
-// fetch requests the specified page and returns the response body.
-func (c *Crawler) fetch(pageURL *url.URL) (io.ReadCloser, error) {
+// fetch requests the specified page and returns the request that was sent
+// along with the response that came back. The caller is responsible for
+// closing resp.Body.
+func (c *Crawler) fetch(pageURL *url.URL) (*http.Request, *http.Response, error) {
 	req, err := http.NewRequest(http.MethodGet, pageURL.String(), nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req.Header.Set("User-Agent", "monzter")
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return resp.Body, nil
+	return req, resp, nil
+}
+
+// relatedSrcTags maps element names that only ever contribute a related
+// resource (never a page to crawl) to the attribute holding their URL.
+var relatedSrcTags = map[string]string{
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+	"source": "src",
+	"iframe": "src",
 }
 
 // uniqueLinksInPage parses the contents of page as HTML and returns all
-// unique URLs found in href attributes of all <a> tags in the HTML tree.
-func (c *Crawler) uniqueLinksInPage(page io.Reader, pageURL *url.URL) ([]*url.URL, error) {
+// unique URLs found on it. Links in <a href> attributes are tagged
+// TagPrimary, unless disallowed from being followed (see below), in which
+// case they are tagged TagRelated instead. Links to related resources -
+// <link href>, <img src>, <script src>, <source src>, <iframe src>, and CSS
+// url(...) references in inline <style> blocks and style attributes - are
+// always tagged TagRelated.
+//
+// A page-wide <meta name="robots" content="nofollow"> drops every <a href>
+// link from the result entirely. A per-link rel="nofollow" keeps its link
+// in the result, tagged TagRelated, so it is recorded but never crawled.
+func (c *Crawler) uniqueLinksInPage(page io.Reader, pageURL *url.URL) ([]Link, error) {
 	doc, err := html.Parse(page)
 	if err != nil {
 		return nil, errors.Wrap(err, "error parsing page")
 	}
 
-	// walkLinks recursively walks the HTML tree and
-	// calls visitLink on every <a> tag it encounters
-	var walkLinks func(*html.Node, func(*html.Node))
-	walkLinks = func(n *html.Node, visitLink func(n *html.Node)) {
-		if n.Type == html.ElementNode && n.Data == "a" {
-			visitLink(n)
-			// let's assume there are no nested <a> tags
-			// https://html.spec.whatwg.org/#the-a-element
+	links := []Link{}
+	seenOnPage := SchemelessURLSet{}
+	pageNoFollow := false
+
+	addLink := func(raw string, tag Tag) {
+		href, err := c.parseToAbsURL(raw, pageURL)
+		if err != nil {
+			// ignore malformed links
 			return
 		}
+		if !seenOnPage.EnsureContains(href) {
+			links = append(links, Link{URL: href, Tag: tag})
+		}
+	}
 
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			walkLinks(c, visitLink)
+	addCSSURLs := func(css string) {
+		for _, match := range cssURLRegexp.FindAllStringSubmatch(css, -1) {
+			addLink(match[1], TagRelated)
 		}
 	}
 
-	links := []*url.URL{}
-	seenOnPage := SchemelessURLSet{}
+	// walk recursively walks the HTML tree, extracting tagged links from
+	// every node it encounters. It relies on <meta name="robots"> appearing
+	// before any <a> tags, as is the case for well-formed pages that put it
+	// in <head>.
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if n.Data == "meta" {
+				if name, ok := attrVal(n, "name"); ok && strings.EqualFold(name, "robots") {
+					if content, ok := attrVal(n, "content"); ok && hasRobotsDirective(content, "nofollow") {
+						pageNoFollow = true
+					}
+				}
+			}
 
-	walkLinks(doc, func(n *html.Node) {
-		for _, attr := range n.Attr {
-			if attr.Key == "href" {
-				href, err := parseToAbsURL(attr.Val, pageURL)
-				if err != nil {
-					// ignore malformed links
-					return
+			if n.Data == "a" {
+				if href, ok := attrVal(n, "href"); ok {
+					rel, _ := attrVal(n, "rel")
+					switch {
+					case pageNoFollow:
+						// dropped entirely, per the page-wide meta directive
+					case hasRobotsDirective(rel, "nofollow"):
+						addLink(href, TagRelated)
+					default:
+						addLink(href, TagPrimary)
+					}
 				}
-				if !seenOnPage.EnsureContains(href) {
-					links = append(links, href)
+			} else if attrName, ok := relatedSrcTags[n.Data]; ok {
+				if href, ok := attrVal(n, attrName); ok {
+					addLink(href, TagRelated)
 				}
-				return
 			}
+
+			if n.Data == "style" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				addCSSURLs(n.FirstChild.Data)
+			}
+
+			if style, ok := attrVal(n, "style"); ok {
+				addCSSURLs(style)
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
 		}
-	})
+	}
+
+	walk(doc)
 
 	return links, nil
 }
+
+// hasRobotsDirective reports whether directive is one of the comma- and/or
+// whitespace-separated values in value, as found in a rel attribute or a
+// <meta name="robots"> content attribute.
+func hasRobotsDirective(value, directive string) bool {
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		if strings.EqualFold(field, directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// attrVal returns the value of the attribute named key on n, if present.
+func attrVal(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
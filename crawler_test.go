@@ -105,12 +105,17 @@ func TestCrawler(t *testing.T) {
 			},
 			maxDepth:         100,
 			maxReqsPerSecond: 100.0,
+			// /entry links to both /depth1 and /depth4 directly, and the
+			// crawler marks both visited (and queues /depth4 for crawling)
+			// before a worker gets to crawl the /depth1 -> ... -> /depth4
+			// chain, so /depth4 (and its only link, back to /entry) ends up
+			// nested under /entry's own link, not under /depth1's chain.
 			expected: `{base}/depth1
   {base}/depth2
     {base}/depth3
       {base}/depth4
-        {base}/entry
 {base}/depth4
+  {base}/entry
 `,
 		},
 		{
@@ -217,3 +222,217 @@ https://google.com/maps
 		tearDown()
 	}
 }
+
+func TestUniqueLinksInPage(t *testing.T) {
+	c, err := NewCrawler("http://example.com/entry", 5, 100.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageURL, _ := url.Parse("http://example.com/entry")
+
+	page := strings.NewReader(`<!doctype html><html><head>
+		<link rel="stylesheet" href="/style.css">
+		<style>body { background: url("bg.png"); }</style>
+	</head><body style="background-image:url('/inline.png')">
+		<a href="/foo">foo</a>
+		<img src="/logo.png">
+		<script src="/app.js"></script>
+		<source src="/video.mp4">
+		<iframe src="/frame.html"></iframe>
+	</body></html>`)
+
+	links, err := c.uniqueLinksInPage(page, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]Tag{}
+	for _, link := range links {
+		got[link.URL.String()] = link.Tag
+	}
+
+	expected := map[string]Tag{
+		"http://example.com/style.css":  TagRelated,
+		"http://example.com/bg.png":     TagRelated,
+		"http://example.com/inline.png": TagRelated,
+		"http://example.com/foo":        TagPrimary,
+		"http://example.com/logo.png":   TagRelated,
+		"http://example.com/app.js":     TagRelated,
+		"http://example.com/video.mp4":  TagRelated,
+		"http://example.com/frame.html": TagRelated,
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d links, got %d: %v", len(expected), len(got), got)
+	}
+
+	for link, tag := range expected {
+		gotTag, ok := got[link]
+		if !ok {
+			t.Errorf("expected link %s to be found", link)
+			continue
+		}
+		if gotTag != tag {
+			t.Errorf("expected link %s to be tagged %v, got %v", link, tag, gotTag)
+		}
+	}
+}
+
+func TestUniqueLinksInPageMultipleCSSURLs(t *testing.T) {
+	c, err := NewCrawler("http://example.com/entry", 5, 100.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageURL, _ := url.Parse("http://example.com/entry")
+
+	page := strings.NewReader(`<!doctype html><html><head>
+		<style>a { background: url(a.png); } b { background: url(b.png); }</style>
+	</head><body></body></html>`)
+
+	links, err := c.uniqueLinksInPage(page, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, link := range links {
+		got[link.URL.String()] = true
+	}
+
+	for _, want := range []string{"http://example.com/a.png", "http://example.com/b.png"} {
+		if !got[want] {
+			t.Errorf("expected %s to be found among %v", want, got)
+		}
+	}
+}
+
+func TestUniqueLinksInPageNoFollow(t *testing.T) {
+	c, err := NewCrawler("http://example.com/entry", 5, 100.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageURL, _ := url.Parse("http://example.com/entry")
+
+	page := strings.NewReader(`<!doctype html><html><head>
+		<meta name="robots" content="noindex, nofollow">
+	</head><body>
+		<a href="/foo" rel="nofollow">foo</a>
+		<a href="/bar">bar</a>
+	</body></html>`)
+
+	links, err := c.uniqueLinksInPage(page, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(links) != 0 {
+		t.Fatalf("expected a page-wide nofollow to drop every <a> link, got %v", links)
+	}
+}
+
+func TestUniqueLinksInPagePerLinkNoFollow(t *testing.T) {
+	c, err := NewCrawler("http://example.com/entry", 5, 100.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageURL, _ := url.Parse("http://example.com/entry")
+
+	page := strings.NewReader(`<!doctype html><html><body>
+		<a href="/foo" rel="nofollow">foo</a>
+		<a href="/bar">bar</a>
+	</body></html>`)
+
+	links, err := c.uniqueLinksInPage(page, pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]Tag{}
+	for _, link := range links {
+		got[link.URL.String()] = link.Tag
+	}
+
+	expected := map[string]Tag{
+		"http://example.com/foo": TagRelated,
+		"http://example.com/bar": TagPrimary,
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d links, got %d: %v", len(expected), len(got), got)
+	}
+
+	for link, tag := range expected {
+		if gotTag := got[link]; gotTag != tag {
+			t.Errorf("expected link %s to be tagged %v, got %v", link, tag, gotTag)
+		}
+	}
+}
+
+func TestCrawlerRespectsRobotsTxt(t *testing.T) {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /secret\n"))
+	})
+	mux.HandleFunc("/entry", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`<a href="/foo">foo</a><a href="/secret">secret</a>`))
+	})
+	mux.HandleFunc("/foo", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`no links here`))
+	})
+	mux.HandleFunc("/secret", func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("a path disallowed by robots.txt was fetched")
+	})
+
+	c, err := NewCrawler(ts.URL+"/entry", 5, 100.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := c.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ts.URL + "/foo\n" + ts.URL + "/secret\n"
+	if got := tree.String(); got != expected {
+		t.Errorf("expected\n%sbut got\n%s", expected, got)
+	}
+}
+
+func TestCrawlerIgnoreRobots(t *testing.T) {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /secret\n"))
+	})
+	mux.HandleFunc("/entry", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`<a href="/secret">secret</a>`))
+	})
+	mux.HandleFunc("/secret", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`no links here`))
+	})
+
+	c, err := NewCrawler(ts.URL+"/entry", 5, 100.0, WithIgnoreRobots(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := c.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ts.URL + "/secret\n"
+	if got := tree.String(); got != expected {
+		t.Errorf("expected\n%sbut got\n%s", expected, got)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "net/url"
+
+// Tag describes the role a Link played on the page it was found on.
+type Tag int
+
+const (
+	// TagPrimary marks links found in <a href> attributes: the pages the
+	// crawler follows.
+	TagPrimary Tag = iota
+	// TagRelated marks links to related resources (images, scripts,
+	// stylesheets, iframes, ...) that are recorded but not followed.
+	TagRelated
+)
+
+// Link is a URL found on a page, tagged with the role it played there.
+type Link struct {
+	URL *url.URL
+	Tag Tag
+}
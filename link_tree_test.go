@@ -2,7 +2,7 @@ package main
 
 import "testing"
 
-func TestString(t *testing.T) {
+func TestLinkTreeString(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    LinkTree
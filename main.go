@@ -4,11 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 )
 
 type configuration struct {
 	maxDepth          int
 	requestsPerSecond float64
+	concurrency       int
+	archivePath       string
+	ignoreRobots      bool
+	statePath         string
 	entrypoint        string
 }
 
@@ -17,6 +22,10 @@ func parseArgs() *configuration {
 
 	flag.IntVar(&c.maxDepth, "depth", 1, "maximum depth to crawl links")
 	flag.Float64Var(&c.requestsPerSecond, "rate", 10.0, "maximum number of requests per second")
+	flag.IntVar(&c.concurrency, "concurrency", 8, "number of pages to crawl concurrently")
+	flag.StringVar(&c.archivePath, "archive", "", "if set, archive every crawled page as WARC records to this .warc.gz file")
+	flag.BoolVar(&c.ignoreRobots, "ignore-robots", false, "ignore robots.txt and crawl every in-scope link")
+	flag.StringVar(&c.statePath, "state", "", "if set, persist crawl progress to this directory and resume from it if it already holds a crawl")
 
 	flag.Parse()
 
@@ -33,7 +42,56 @@ func parseArgs() *configuration {
 func main() {
 	config := parseArgs()
 
-	c, err := NewCrawler(config.entrypoint, config.maxDepth, config.requestsPerSecond)
+	var archiver Archiver
+	var warcArchiver *WARCArchiver
+	if config.archivePath != "" {
+		a, err := NewWARCArchiver(config.archivePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		warcArchiver = a
+		archiver = a
+	}
+
+	var state *StateStore
+	if config.statePath != "" {
+		s, err := OpenStateStore(config.statePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		state = s
+	}
+
+	// a killed process does not run deferred functions, so close the
+	// archive and state files explicitly on SIGINT to flush them cleanly
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
+		<-sigCh
+		if warcArchiver != nil {
+			warcArchiver.Close()
+		}
+		if state != nil {
+			state.Close()
+		}
+		os.Exit(130)
+	}()
+
+	if warcArchiver != nil {
+		defer warcArchiver.Close()
+	}
+	if state != nil {
+		defer state.Close()
+	}
+
+	c, err := NewCrawler(config.entrypoint, config.maxDepth, config.requestsPerSecond,
+		WithConcurrency(config.concurrency),
+		WithArchiver(archiver),
+		WithIgnoreRobots(config.ignoreRobots),
+		WithState(state),
+	)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		return
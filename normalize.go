@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// NormalizeOptions is a set of normalizations a Normalize function may
+// apply to a URL.
+type NormalizeOptions uint
+
+const (
+	// RemoveDotSegments resolves "." and ".." path segments, e.g. turning
+	// "/foo/../bar" into "/bar".
+	RemoveDotSegments NormalizeOptions = 1 << iota
+	// RemoveDuplicateSlashes collapses consecutive slashes in the path
+	// into a single one.
+	RemoveDuplicateSlashes
+	// RemoveFragment strips the "#fragment" part of the URL.
+	RemoveFragment
+	// RemoveDirectoryIndex strips a trailing default document name (such
+	// as "index.html") from the path.
+	RemoveDirectoryIndex
+	// SortQuery reorders query parameters alphabetically by key.
+	SortQuery
+	// RemoveTrailingSlash strips a trailing slash from the path. This is
+	// not part of DefaultNormalization, since it makes "/foo" and "/foo/"
+	// indistinguishable, which not every site treats as equivalent.
+	RemoveTrailingSlash
+)
+
+// DefaultNormalization is the set of options applied by DefaultNormalize.
+const DefaultNormalization = RemoveDotSegments | RemoveDuplicateSlashes | RemoveFragment | RemoveDirectoryIndex | SortQuery
+
+// DefaultNormalize is the Normalize function used by NewCrawler when none
+// is specified.
+var DefaultNormalize = Normalize(DefaultNormalization)
+
+var (
+	duplicateSlashes = regexp.MustCompile(`/{2,}`)
+	directoryIndex   = regexp.MustCompile(`/(?:index|default)\.\w+$`)
+)
+
+// Normalize returns a function applying the normalizations selected by
+// opts to a URL, for use as the Normalize field of NewCrawler.
+func Normalize(opts NormalizeOptions) func(*url.URL) *url.URL {
+	return func(u *url.URL) *url.URL {
+		normalized := *u
+
+		if opts&RemoveFragment != 0 {
+			normalized.Fragment = ""
+		}
+
+		if opts&RemoveDotSegments != 0 {
+			normalized.Path = removeDotSegments(normalized.Path)
+		}
+
+		if opts&RemoveDuplicateSlashes != 0 {
+			normalized.Path = duplicateSlashes.ReplaceAllString(normalized.Path, "/")
+		}
+
+		if opts&RemoveDirectoryIndex != 0 {
+			normalized.Path = directoryIndex.ReplaceAllString(normalized.Path, "/")
+		}
+
+		if opts&SortQuery != 0 {
+			// url.Values.Encode() already sorts by key.
+			normalized.RawQuery = normalized.Query().Encode()
+		}
+
+		if opts&RemoveTrailingSlash != 0 && len(normalized.Path) > 1 {
+			normalized.Path = strings.TrimSuffix(normalized.Path, "/")
+		}
+
+		return &normalized
+	}
+}
+
+// removeDotSegments resolves "." and ".." segments in p, preserving a
+// trailing slash if p had one.
+func removeDotSegments(p string) string {
+	hadTrailingSlash := len(p) > 1 && strings.HasSuffix(p, "/")
+
+	segments := strings.Split(p, "/")
+	resolved := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		switch segment {
+		case ".":
+			// drop
+		case "..":
+			// never pop the leading "" segment that marks the root
+			if len(resolved) > 1 {
+				resolved = resolved[:len(resolved)-1]
+			}
+		default:
+			resolved = append(resolved, segment)
+		}
+	}
+
+	cleaned := strings.Join(resolved, "/")
+	if cleaned == "" {
+		cleaned = "/"
+	}
+	if hadTrailingSlash && !strings.HasSuffix(cleaned, "/") {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
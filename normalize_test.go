@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     NormalizeOptions
+		input    string
+		expected string
+	}{
+		{
+			name:     "remove dot segments",
+			opts:     RemoveDotSegments,
+			input:    "http://example.com/foo/../bar",
+			expected: "http://example.com/bar",
+		},
+		{
+			name:     "remove dot segments keeps trailing slash",
+			opts:     RemoveDotSegments,
+			input:    "http://example.com/foo/./bar/../",
+			expected: "http://example.com/foo/",
+		},
+		{
+			name:     "remove duplicate slashes",
+			opts:     RemoveDuplicateSlashes,
+			input:    "http://example.com/foo//bar///baz",
+			expected: "http://example.com/foo/bar/baz",
+		},
+		{
+			name:     "remove fragment",
+			opts:     RemoveFragment,
+			input:    "http://example.com/foo#section",
+			expected: "http://example.com/foo",
+		},
+		{
+			name:     "remove directory index",
+			opts:     RemoveDirectoryIndex,
+			input:    "http://example.com/foo/index.html",
+			expected: "http://example.com/foo/",
+		},
+		{
+			name:     "sort query",
+			opts:     SortQuery,
+			input:    "http://example.com/foo?b=2&a=1",
+			expected: "http://example.com/foo?a=1&b=2",
+		},
+		{
+			name:     "remove trailing slash",
+			opts:     RemoveTrailingSlash,
+			input:    "http://example.com/foo/",
+			expected: "http://example.com/foo",
+		},
+		{
+			name:     "remove trailing slash leaves root alone",
+			opts:     RemoveTrailingSlash,
+			input:    "http://example.com/",
+			expected: "http://example.com/",
+		},
+		{
+			name:     "default normalization leaves trailing slash alone",
+			opts:     DefaultNormalization,
+			input:    "http://example.com/foo/",
+			expected: "http://example.com/foo/",
+		},
+	}
+
+	for _, test := range tests {
+		u := mustParse(t, test.input)
+		got := Normalize(test.opts)(u).String()
+		if got != test.expected {
+			t.Errorf("test '%s' failed: expected %s, got %s", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import "net/url"
+
+// queueItem is a unit of crawl work: a URL to fetch, paired with the depth
+// it was found at.
+type queueItem struct {
+	url   *url.URL
+	depth int
+}
+
+// queue is an unbounded FIFO queue of queueItems. Unlike a plain buffered
+// channel, sending to in never blocks on a full buffer, which matters here:
+// workers both consume from out and produce onto in, so a bounded channel
+// could deadlock once every worker is blocked trying to enqueue a child
+// link found on the page it is currently processing.
+type queue struct {
+	in  chan queueItem
+	out chan queueItem
+}
+
+// newQueue returns a running queue. Send items on q.in; receive them, in
+// the order they were sent, on q.out. Close q.in once no more items will be
+// sent; q.out is closed once all buffered items have been drained.
+func newQueue() *queue {
+	q := &queue{
+		in:  make(chan queueItem),
+		out: make(chan queueItem),
+	}
+	go q.run()
+	return q
+}
+
+func (q *queue) run() {
+	var buf []queueItem
+
+	for {
+		if len(buf) == 0 {
+			item, ok := <-q.in
+			if !ok {
+				close(q.out)
+				return
+			}
+			buf = append(buf, item)
+			continue
+		}
+
+		select {
+		case item, ok := <-q.in:
+			if !ok {
+				for _, item := range buf {
+					q.out <- item
+				}
+				close(q.out)
+				return
+			}
+			buf = append(buf, item)
+		case q.out <- buf[0]:
+			buf = buf[1:]
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package main
 
-import "sync"
+import (
+	"net/url"
+	"sync"
+)
 
+// result is what a crawl worker reports back after processing one page:
+// the links found directly on it (with no sub trees filled in yet), or the
+// error encountered while fetching or parsing it.
 type result struct {
-	link string
-	tree LinkTree
+	link *url.URL
+	tree URLTree
 	err  error
 }
 
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// robotsUserAgent is the user agent monzter identifies itself as when
+// evaluating robots.txt rules, matching the User-Agent header sent by
+// Crawler.fetch.
+const robotsUserAgent = "monzter"
+
+// robotsPolicy holds the allow/disallow rules from one host's robots.txt
+// that apply to robotsUserAgent (or, absent a matching group, to "*").
+type robotsPolicy struct {
+	rules []robotsRule
+}
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	prefix  string
+	allowed bool
+}
+
+// Allowed reports whether path may be crawled under p. The longest matching
+// rule wins; ties are resolved in favor of allowing the path. A nil policy,
+// or one with no matching rules, allows everything.
+func (p *robotsPolicy) Allowed(path string) bool {
+	if p == nil {
+		return true
+	}
+
+	allowed := true
+	longestMatch := -1
+	for _, rule := range p.rules {
+		if !strings.HasPrefix(path, rule.prefix) {
+			continue
+		}
+		if len(rule.prefix) > longestMatch || (len(rule.prefix) == longestMatch && rule.allowed) {
+			longestMatch = len(rule.prefix)
+			allowed = rule.allowed
+		}
+	}
+
+	return allowed
+}
+
+// parseRobotsTxt parses the contents of a robots.txt file and returns the
+// policy that applies to userAgent, falling back to the "*" group if no
+// group names userAgent specifically.
+func parseRobotsTxt(body, userAgent string) *robotsPolicy {
+	type group struct {
+		agents []string
+		rules  []robotsRule
+	}
+
+	var groups []*group
+	var current *group
+	sawRule := false
+
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			// consecutive User-agent lines belong to the same group; a
+			// User-agent line after rules have been seen starts a new one
+			if current == nil || sawRule {
+				current = &group{}
+				groups = append(groups, current)
+				sawRule = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			sawRule = true
+			current.rules = append(current.rules, robotsRule{prefix: value, allowed: field == "allow"})
+		}
+	}
+
+	userAgent = strings.ToLower(userAgent)
+	var exact, wildcard *group
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == userAgent {
+				exact = g
+			}
+			if agent == "*" {
+				wildcard = g
+			}
+		}
+	}
+
+	switch {
+	case exact != nil:
+		return &robotsPolicy{rules: exact.rules}
+	case wildcard != nil:
+		return &robotsPolicy{rules: wildcard.rules}
+	default:
+		return &robotsPolicy{}
+	}
+}
+
+// robotsPolicyFor returns the cached robots.txt policy for u's host,
+// fetching and parsing it first if this is the first time this host is
+// seen. A robots.txt that can't be fetched or doesn't return 200 OK is
+// treated as imposing no restrictions, rather than blocking the whole host.
+// Concurrent first-fetches of the same host are collapsed into a single
+// request via c.robotsOnce, so a burst of workers hitting a new host at
+// once can't each kick off their own robots.txt fetch.
+func (c *Crawler) robotsPolicyFor(u *url.URL) *robotsPolicy {
+	c.robotsMu.Lock()
+	policy, ok := c.robotsCache[u.Host]
+	if ok {
+		c.robotsMu.Unlock()
+		return policy
+	}
+
+	once, ok := c.robotsOnce[u.Host]
+	if !ok {
+		once = &sync.Once{}
+		c.robotsOnce[u.Host] = once
+	}
+	c.robotsMu.Unlock()
+
+	once.Do(func() {
+		policy := c.fetchRobotsPolicy(u)
+
+		c.robotsMu.Lock()
+		c.robotsCache[u.Host] = policy
+		c.robotsMu.Unlock()
+	})
+
+	c.robotsMu.Lock()
+	policy = c.robotsCache[u.Host]
+	c.robotsMu.Unlock()
+
+	return policy
+}
+
+// fetchRobotsPolicy fetches and parses the robots.txt at the same scheme
+// and host as u. The request is subject to c.limiter, the same as any
+// other outgoing request.
+func (c *Crawler) fetchRobotsPolicy(u *url.URL) *robotsPolicy {
+	robotsURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	// make sure we stay nice
+	if err := c.limiter.Wait(context.Background()); err != nil {
+		return &robotsPolicy{}
+	}
+
+	resp, err := c.client.Get(robotsURL.String())
+	if err != nil {
+		return &robotsPolicy{}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &robotsPolicy{}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &robotsPolicy{}
+	}
+
+	return parseRobotsTxt(string(body), robotsUserAgent)
+}
+
+// robotsAllow reports whether u may be crawled: always true if
+// c.ignoreRobots is set, otherwise the result of consulting u's host's
+// robots.txt policy.
+func (c *Crawler) robotsAllow(u *url.URL) bool {
+	if c.ignoreRobots {
+		return true
+	}
+
+	return c.robotsPolicyFor(u).Allowed(u.Path)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRobotsPolicyAllowed(t *testing.T) {
+	policy := parseRobotsTxt(`
+User-agent: monzter
+Disallow: /private
+Allow: /private/public
+
+User-agent: *
+Disallow: /
+`, "monzter")
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/foo", true},
+		{"/private", false},
+		{"/private/bar", false},
+		{"/private/public", true},
+		{"/private/public/deeper", true},
+	}
+
+	for _, test := range tests {
+		if got := policy.Allowed(test.path); got != test.expected {
+			t.Errorf("path %s: expected allowed=%v, got %v", test.path, test.expected, got)
+		}
+	}
+}
+
+func TestRobotsPolicyFallsBackToWildcard(t *testing.T) {
+	policy := parseRobotsTxt(`
+User-agent: *
+Disallow: /admin
+`, "monzter")
+
+	if policy.Allowed("/admin") {
+		t.Error("expected /admin to be disallowed by the wildcard group")
+	}
+	if !policy.Allowed("/foo") {
+		t.Error("expected /foo to be allowed")
+	}
+}
+
+func TestRobotsPolicyAllowsEverythingWithoutMatchingGroup(t *testing.T) {
+	policy := parseRobotsTxt(`
+User-agent: someotherbot
+Disallow: /
+`, "monzter")
+
+	if !policy.Allowed("/anything") {
+		t.Error("expected a policy with no matching group to allow everything")
+	}
+}
+
+func TestRobotsPolicyForCollapsesConcurrentFirstFetches(t *testing.T) {
+	var fetches int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write([]byte("User-agent: *\nDisallow: /secret\n"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	c, err := NewCrawler(ts.URL+"/entry", 5, 100.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(ts.URL + "/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.robotsAllow(u)
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("expected robots.txt to be fetched exactly once for concurrent first-fetches of the same host, got %d", got)
+	}
+}
+
+func TestHasRobotsDirective(t *testing.T) {
+	if !hasRobotsDirective("noindex, nofollow", "nofollow") {
+		t.Error("expected 'nofollow' to be found in 'noindex, nofollow'")
+	}
+	if hasRobotsDirective("noindex", "nofollow") {
+		t.Error("expected 'nofollow' not to be found in 'noindex'")
+	}
+}
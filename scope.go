@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Scope decides whether the crawler should follow a link. Check is called
+// with the URL found on a page and the depth it would be crawled at (i.e.
+// the depth of the page it was found on, plus one).
+type Scope interface {
+	Check(u *url.URL, depth int) bool
+}
+
+// DepthScope allows crawling links up to, but excluding, the given depth.
+type DepthScope int
+
+// Check implements Scope.
+func (max DepthScope) Check(u *url.URL, depth int) bool {
+	return depth < int(max)
+}
+
+// HostScope allows crawling links whose hostname is one of hosts.
+type HostScope []string
+
+// Check implements Scope.
+func (s HostScope) Check(u *url.URL, depth int) bool {
+	for _, host := range s {
+		if u.Hostname() == host {
+			return true
+		}
+	}
+	return false
+}
+
+// SchemeScope allows crawling links whose scheme is one of schemes.
+type SchemeScope []string
+
+// Check implements Scope.
+func (s SchemeScope) Check(u *url.URL, depth int) bool {
+	for _, scheme := range s {
+		if u.Scheme == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// seedScope allows crawling links whose hostname matches one of seeds,
+// or, if includeSubdomains is set, a subdomain of one of seeds.
+type seedScope struct {
+	seeds             []*url.URL
+	includeSubdomains bool
+}
+
+// SeedScope returns a Scope that allows crawling links on the same host as
+// one of seeds. If includeSubdomains is true, links on subdomains of a seed
+// are allowed too.
+func SeedScope(seeds []*url.URL, includeSubdomains bool) Scope {
+	return seedScope{seeds: seeds, includeSubdomains: includeSubdomains}
+}
+
+// Check implements Scope.
+func (s seedScope) Check(u *url.URL, depth int) bool {
+	host := u.Hostname()
+	for _, seed := range s.seeds {
+		seedHost := seed.Hostname()
+		if host == seedHost {
+			return true
+		}
+		if s.includeSubdomains && strings.HasSuffix(host, "."+seedHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// regexScope allows crawling links matching include (if non-nil) and not
+// matching exclude (if non-nil).
+type regexScope struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// RegexScope returns a Scope that allows crawling links whose string form
+// matches include (unless include is nil, in which case all links match)
+// and does not match exclude (unless exclude is nil, in which case no link
+// is excluded).
+func RegexScope(include, exclude *regexp.Regexp) Scope {
+	return regexScope{include: include, exclude: exclude}
+}
+
+// Check implements Scope.
+func (s regexScope) Check(u *url.URL, depth int) bool {
+	str := u.String()
+	if s.include != nil && !s.include.MatchString(str) {
+		return false
+	}
+	if s.exclude != nil && s.exclude.MatchString(str) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", s, err)
+	}
+	return u
+}
+
+func TestDepthScope(t *testing.T) {
+	scope := DepthScope(3)
+
+	if !scope.Check(nil, 0) {
+		t.Error("expected depth 0 to be in scope")
+	}
+	if !scope.Check(nil, 2) {
+		t.Error("expected depth 2 to be in scope")
+	}
+	if scope.Check(nil, 3) {
+		t.Error("expected depth 3 to be out of scope")
+	}
+	if scope.Check(nil, 4) {
+		t.Error("expected depth 4 to be out of scope")
+	}
+}
+
+func TestHostScope(t *testing.T) {
+	scope := HostScope{"example.com", "example.org"}
+
+	if !scope.Check(mustParse(t, "http://example.com/foo"), 0) {
+		t.Error("expected example.com to be in scope")
+	}
+	if !scope.Check(mustParse(t, "http://example.org/foo"), 0) {
+		t.Error("expected example.org to be in scope")
+	}
+	if scope.Check(mustParse(t, "http://example.net/foo"), 0) {
+		t.Error("expected example.net to be out of scope")
+	}
+	if scope.Check(mustParse(t, "http://sub.example.com/foo"), 0) {
+		t.Error("expected sub.example.com to be out of scope")
+	}
+}
+
+func TestSchemeScope(t *testing.T) {
+	scope := SchemeScope{"https"}
+
+	if scope.Check(mustParse(t, "http://example.com/foo"), 0) {
+		t.Error("expected http to be out of scope")
+	}
+	if !scope.Check(mustParse(t, "https://example.com/foo"), 0) {
+		t.Error("expected https to be in scope")
+	}
+}
+
+func TestSeedScope(t *testing.T) {
+	seeds := []*url.URL{mustParse(t, "https://example.com/start")}
+
+	exact := SeedScope(seeds, false)
+	if !exact.Check(mustParse(t, "https://example.com/foo"), 0) {
+		t.Error("expected same host as seed to be in scope")
+	}
+	if exact.Check(mustParse(t, "https://sub.example.com/foo"), 0) {
+		t.Error("expected subdomain of seed to be out of scope when includeSubdomains is false")
+	}
+
+	withSubdomains := SeedScope(seeds, true)
+	if !withSubdomains.Check(mustParse(t, "https://sub.example.com/foo"), 0) {
+		t.Error("expected subdomain of seed to be in scope when includeSubdomains is true")
+	}
+	if withSubdomains.Check(mustParse(t, "https://notexample.com/foo"), 0) {
+		t.Error("expected unrelated host to be out of scope")
+	}
+}
+
+func TestRegexScope(t *testing.T) {
+	include := regexp.MustCompile(`/articles/`)
+	exclude := regexp.MustCompile(`/logout`)
+
+	scope := RegexScope(include, exclude)
+
+	if !scope.Check(mustParse(t, "https://example.com/articles/foo"), 0) {
+		t.Error("expected matching include to be in scope")
+	}
+	if scope.Check(mustParse(t, "https://example.com/other"), 0) {
+		t.Error("expected non-matching include to be out of scope")
+	}
+	if scope.Check(mustParse(t, "https://example.com/articles/logout"), 0) {
+		t.Error("expected matching exclude to be out of scope")
+	}
+
+	noInclude := RegexScope(nil, exclude)
+	if !noInclude.Check(mustParse(t, "https://example.com/other"), 0) {
+		t.Error("expected any link to match a nil include")
+	}
+
+	noExclude := RegexScope(include, nil)
+	if !noExclude.Check(mustParse(t, "https://example.com/articles/logout"), 0) {
+		t.Error("expected no link to be excluded by a nil exclude")
+	}
+}
+
+func TestComposedScopes(t *testing.T) {
+	c, err := NewCrawler("https://example.com/entry", 100, 100.0, WithScopes([]Scope{
+		DepthScope(2),
+		SchemeScope{"https"},
+		RegexScope(nil, regexp.MustCompile(`/logout`)),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		u        *url.URL
+		depth    int
+		expected bool
+	}{
+		{"within depth, https, not excluded", mustParse(t, "https://example.com/foo"), 1, true},
+		{"too deep", mustParse(t, "https://example.com/foo"), 2, false},
+		{"wrong scheme", mustParse(t, "http://example.com/foo"), 1, false},
+		{"excluded path", mustParse(t, "https://example.com/logout"), 1, false},
+	}
+
+	for _, test := range tests {
+		if got := c.inScope(test.u, test.depth); got != test.expected {
+			t.Errorf("test '%s' failed: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/gob"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// stateFileName is the name of the append-only log file a StateStore keeps
+// inside its state directory.
+const stateFileName = "state.gob"
+
+// stateRecord is the on-disk representation of one event in a StateStore's
+// log: either a URL's depth and crawl status (Kind == "url"), or a tree
+// edge from Parent to the URL in the Child field (Kind == "edge").
+type stateRecord struct {
+	Kind   string
+	URL    string
+	Depth  int
+	Status string
+	Parent string
+}
+
+// urlInfo is the last known depth and status recorded for a URL.
+type urlInfo struct {
+	Depth  int
+	Status string // "pending", "done", or "error"
+}
+
+// edgeInfo is a single (parent, child) tree edge recovered from a
+// StateStore's log.
+type edgeInfo struct {
+	Parent string
+	Child  string
+}
+
+// ResumeState is everything recovered from a StateStore's log file when it
+// is opened.
+type ResumeState struct {
+	URLs  map[string]urlInfo
+	Edges []edgeInfo
+}
+
+// StateStore persists a crawl's visited URLs and link tree to an
+// append-only gob log, so that a crawl can resume where a previous,
+// interrupted run left off.
+type StateStore struct {
+	mu      sync.Mutex
+	file    *os.File
+	enc     *gob.Encoder
+	Resumed *ResumeState
+}
+
+// OpenStateStore loads any state previously persisted under dir, then
+// returns a StateStore appending further records to it. dir is created if
+// it doesn't already exist.
+func OpenStateStore(dir string) (*StateStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create state directory %s", dir)
+	}
+
+	path := filepath.Join(dir, stateFileName)
+
+	resumed, err := loadResumeState(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state from %s", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open state file %s", path)
+	}
+
+	return &StateStore{file: f, enc: gob.NewEncoder(f), Resumed: resumed}, nil
+}
+
+// loadResumeState replays every record in the log file at path, keeping
+// only the most recently recorded status for each URL.
+func loadResumeState(path string) (*ResumeState, error) {
+	resumed := &ResumeState{URLs: map[string]urlInfo{}}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return resumed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(f)
+	for {
+		var rec stateRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch rec.Kind {
+		case "url":
+			resumed.URLs[rec.URL] = urlInfo{Depth: rec.Depth, Status: rec.Status}
+		case "edge":
+			resumed.Edges = append(resumed.Edges, edgeInfo{Parent: rec.Parent, Child: rec.URL})
+		}
+	}
+
+	return resumed, nil
+}
+
+// writeRecord appends rec to the log and fsyncs it, so that a crash leaves
+// the log consistent up to the last completed write.
+func (s *StateStore) writeRecord(rec stateRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(rec); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+// RecordPending persists that u was discovered at depth and queued to be
+// crawled.
+func (s *StateStore) RecordPending(u *url.URL, depth int) error {
+	return s.writeRecord(stateRecord{Kind: "url", URL: u.String(), Depth: depth, Status: "pending"})
+}
+
+// RecordDone persists that u was crawled successfully.
+func (s *StateStore) RecordDone(u *url.URL) error {
+	return s.writeRecord(stateRecord{Kind: "url", URL: u.String(), Status: "done"})
+}
+
+// RecordError persists that crawling u failed.
+func (s *StateStore) RecordError(u *url.URL) error {
+	return s.writeRecord(stateRecord{Kind: "url", URL: u.String(), Status: "error"})
+}
+
+// RecordEdge persists that child was found linked from parent, so the link
+// tree can be rebuilt on resume.
+func (s *StateStore) RecordEdge(parent, child *url.URL) error {
+	return s.writeRecord(stateRecord{Kind: "edge", Parent: parent.String(), URL: child.String()})
+}
+
+// Close closes the underlying log file.
+func (s *StateStore) Close() error {
+	return s.file.Close()
+}
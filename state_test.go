@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// blockingHandler serves the configured pages, blocking on /slow until
+// release is closed, so a test can simulate a crawl being interrupted
+// mid-request.
+func blockingHandler(pages map[string]string, release <-chan struct{}) http.Handler {
+	mux := http.NewServeMux()
+	for path, body := range pages {
+		path, body := path, body
+		mux.HandleFunc(path, func(w http.ResponseWriter, _ *http.Request) {
+			if path == "/slow" {
+				<-release
+			}
+			w.Write([]byte(body))
+		})
+	}
+	return mux
+}
+
+func TestStateStoreResumesInterruptedCrawl(t *testing.T) {
+	dir := t.TempDir()
+
+	release := make(chan struct{})
+	var once sync.Once
+	closeRelease := func() { once.Do(func() { close(release) }) }
+	defer closeRelease()
+
+	ts := httptest.NewServer(blockingHandler(map[string]string{
+		"/entry": `<a href="/slow">slow</a><a href="/foo">foo</a>`,
+		"/slow":  `<a href="/bar">bar</a>`,
+		"/foo":   `no links here`,
+		"/bar":   `no links here`,
+	}, release))
+	defer ts.Close()
+
+	// first crawl: let /foo finish, but cut it off before /slow (and
+	// therefore /bar) can complete, simulating a crash mid-crawl
+	state1, err := OpenStateStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c1, err := NewCrawler(ts.URL+"/entry", 5, 100.0, WithConcurrency(2), WithState(state1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c1.Run()
+		close(done)
+	}()
+
+	// wait for /foo's "done" record to land before yanking the state out
+	// from under the still-in-flight /slow request
+	waitForRecord(t, dir, "/foo", "done")
+	state1.Close()
+
+	closeRelease()
+	<-done
+
+	// second crawl, using a fresh Crawler backed by the same state
+	// directory: it should pick up exactly where the first one left off
+	state2, err := OpenStateStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state2.Close()
+
+	if len(state2.Resumed.URLs) == 0 {
+		t.Fatal("expected the reopened state store to have recovered URLs from the first crawl")
+	}
+
+	c2, err := NewCrawler(ts.URL+"/entry", 5, 100.0, WithConcurrency(2), WithState(state2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree, err := c2.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ts.URL + "/foo\n" + ts.URL + "/slow\n  " + ts.URL + "/bar\n"
+	if got := tree.String(); got != expected {
+		t.Errorf("expected\n%sbut got\n%s", expected, got)
+	}
+}
+
+// waitForRecord blocks until the state log in dir records the given status
+// for a URL ending in urlSuffix.
+func waitForRecord(t *testing.T, dir, urlSuffix, status string) {
+	t.Helper()
+
+	for i := 0; i < 100000; i++ {
+		resumed, err := loadResumeState(filepath.Join(dir, stateFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for u, info := range resumed.URLs {
+			if info.Status == status && strings.HasSuffix(u, urlSuffix) {
+				return
+			}
+		}
+	}
+
+	t.Fatalf("timed out waiting for a %q record for a URL ending in %q", status, urlSuffix)
+}
@@ -1,7 +1,13 @@
 package main
 
-import "sync"
+import (
+	"net/url"
+	"sync"
+)
 
+// LinkSet uses the scheme-less equivalent of a URL u to determine whether u
+// is an element of the set or not, like SchemelessURLSet, but is safe for
+// concurrent use.
 type LinkSet struct {
 	// a *sync.Map is used here instead of a map[string]struct{}
 	// and *sync.RWMutex, because it is optimized for this use
@@ -15,7 +21,9 @@ func NewLinkSet() *LinkSet {
 	}
 }
 
-func (s LinkSet) EnsureContains(url string) (existed bool) {
-	_, existed = s.m.LoadOrStore(url, struct{}{})
+// EnsureContains adds u and returns false in case u was not contained
+// in s before. Otherwise (if u already was in s), it returns true.
+func (s *LinkSet) EnsureContains(u *url.URL) (existed bool) {
+	_, existed = s.m.LoadOrStore(withoutScheme(u), struct{}{})
 	return
 }
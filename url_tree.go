@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // URLTree is a tree of *url.URLs with pretty printing.
@@ -40,6 +41,34 @@ func (t URLTree) string(indent int) string {
 	return b.String()
 }
 
+// treeRegistry hands out a single shared URLTree for each URL, creating it
+// on first use. Since URLTree is a map, every holder of a given URL's tree
+// observes entries later added to it, which is what lets concurrent crawl
+// workers build up one nested URLTree without a lock covering the whole
+// tree.
+type treeRegistry struct {
+	mu    sync.Mutex
+	trees map[*url.URL]URLTree
+}
+
+func newTreeRegistry() *treeRegistry {
+	return &treeRegistry{trees: map[*url.URL]URLTree{}}
+}
+
+// get returns the shared URLTree for u, creating an empty one if this is
+// the first time u has been seen.
+func (r *treeRegistry) get(u *url.URL) URLTree {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tree, ok := r.trees[u]
+	if !ok {
+		tree = URLTree{}
+		r.trees[u] = tree
+	}
+	return tree
+}
+
 // IgnoringScheme implements sort.Interface and sorts URLs
 // by lexicographical order of their scheme-less equivalents.
 type IgnoringScheme []*url.URL
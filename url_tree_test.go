@@ -17,7 +17,7 @@ func init() {
 	baz, _ = url.Parse("http://example.com/baz")
 }
 
-func TestString(t *testing.T) {
+func TestURLTreeString(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    URLTree
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// warcDateFormat is the timestamp format required by the WARC 1.0
+// specification for WARC-Date: a UTC ISO8601 timestamp with second
+// precision.
+const warcDateFormat = "2006-01-02T15:04:05Z"
+
+// WARCArchiver is an Archiver that writes every page it is given to a
+// gzip-per-record .warc.gz file: each record is compressed independently,
+// so the file is a valid WARC archive as well as a valid concatenation of
+// gzip streams, readable by tools such as pywb and warcat.
+type WARCArchiver struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewWARCArchiver creates (or truncates) the file at path and returns a
+// WARCArchiver writing to it, after writing the initial warcinfo record
+// describing the archive.
+func NewWARCArchiver(path string) (*WARCArchiver, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create %s", path)
+	}
+
+	a := &WARCArchiver{file: f}
+
+	if err := a.writeRecord(warcinfoRecord()); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "failed to write warcinfo record")
+	}
+
+	return a, nil
+}
+
+// Close closes the underlying archive file.
+func (a *WARCArchiver) Close() error {
+	return a.file.Close()
+}
+
+// Record implements Archiver. It appends a "request" record (the raw HTTP
+// request line and headers) and a "response" record (the raw HTTP status
+// line, headers and body) to the archive, as two concurrent records sharing
+// a WARC-Target-URI and WARC-Date.
+func (a *WARCArchiver) Record(req *http.Request, resp *http.Response, body []byte) error {
+	targetURI := req.URL.String()
+	date := time.Now().UTC()
+	requestID := newWARCRecordID()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.writeRecord(warcRecord{
+		recordType:  "request",
+		targetURI:   targetURI,
+		date:        date,
+		recordID:    requestID,
+		contentType: "application/http;msgtype=request",
+		content:     dumpRequest(req),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to write request record for %s", targetURI)
+	}
+
+	err = a.writeRecord(warcRecord{
+		recordType:   "response",
+		targetURI:    targetURI,
+		date:         date,
+		recordID:     newWARCRecordID(),
+		concurrentTo: requestID,
+		contentType:  "application/http;msgtype=response",
+		content:      append(dumpResponseHead(resp), body...),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to write response record for %s", targetURI)
+	}
+
+	return nil
+}
+
+// writeRecord appends r to the archive as its own gzip member.
+func (a *WARCArchiver) writeRecord(r warcRecord) error {
+	gz := gzip.NewWriter(a.file)
+
+	if _, err := gz.Write(r.bytes()); err != nil {
+		gz.Close()
+		return err
+	}
+
+	return gz.Close()
+}
+
+// warcRecord holds the fields needed to serialize a single WARC/1.0 record.
+type warcRecord struct {
+	recordType   string
+	targetURI    string
+	date         time.Time
+	recordID     string
+	concurrentTo string
+	contentType  string
+	content      []byte
+}
+
+// bytes serializes r as a WARC/1.0 record: a block of header lines
+// terminated by a blank line, followed by content and the two CRLFs that
+// terminate a record.
+func (r warcRecord) bytes() []byte {
+	buf := &bytes.Buffer{}
+
+	buf.WriteString("WARC/1.0\r\n")
+	fmt.Fprintf(buf, "WARC-Type: %s\r\n", r.recordType)
+	if r.targetURI != "" {
+		fmt.Fprintf(buf, "WARC-Target-URI: %s\r\n", r.targetURI)
+	}
+	fmt.Fprintf(buf, "WARC-Date: %s\r\n", r.date.Format(warcDateFormat))
+	fmt.Fprintf(buf, "WARC-Record-ID: %s\r\n", r.recordID)
+	if r.concurrentTo != "" {
+		fmt.Fprintf(buf, "WARC-Concurrent-To: %s\r\n", r.concurrentTo)
+	}
+	fmt.Fprintf(buf, "Content-Type: %s\r\n", r.contentType)
+	fmt.Fprintf(buf, "Content-Length: %d\r\n", len(r.content))
+	buf.WriteString("\r\n")
+	buf.Write(r.content)
+	buf.WriteString("\r\n\r\n")
+
+	return buf.Bytes()
+}
+
+// warcinfoRecord describes the software that wrote the archive.
+func warcinfoRecord() warcRecord {
+	content := []byte("software: monzter\r\nformat: WARC File Format 1.0\r\n")
+
+	return warcRecord{
+		recordType:  "warcinfo",
+		date:        time.Now().UTC(),
+		recordID:    newWARCRecordID(),
+		contentType: "application/warc-fields",
+		content:     content,
+	}
+}
+
+// dumpRequest renders req as a raw HTTP/1.1 request: the request line,
+// the Host header, and every header set on req.
+func dumpRequest(req *http.Request) []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	fmt.Fprintf(buf, "Host: %s\r\n", req.URL.Host)
+	req.Header.Write(buf)
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// dumpResponseHead renders the raw HTTP/1.1 status line and headers of
+// resp, not including its body.
+func dumpResponseHead(resp *http.Response) []byte {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "%s %s\r\n", resp.Proto, resp.Status)
+	resp.Header.Write(buf)
+	buf.WriteString("\r\n")
+
+	return buf.Bytes()
+}
+
+// newWARCRecordID returns a new, randomly generated WARC-Record-ID, in the
+// "<urn:uuid:...>" form required by the WARC 1.0 specification.
+func newWARCRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("<urn:uuid:%08x-%04x-%04x-%04x-%012x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readWARCRecords decompresses and returns every gzip member (= WARC
+// record) stored in the file at path, in order.
+func readWARCRecords(t *testing.T, path string) []string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// reuse a single bufio.Reader across gzip.NewReader calls, so that
+	// gzip's internal read-ahead buffering doesn't skip past the start of
+	// the next record in the underlying file.
+	r := bufio.NewReader(f)
+
+	var records []string
+	for {
+		gz, err := gzip.NewReader(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz.Multistream(false) // each gzip member is its own WARC record
+
+		content, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, string(content))
+	}
+
+	return records
+}
+
+func TestWARCArchiver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.warc.gz")
+
+	a, err := NewWARCArchiver(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>hi</body></html>"))
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/page", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Record(req, resp, body); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readWARCRecords(t, path)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 WARC records (warcinfo, request, response), got %d", len(records))
+	}
+
+	if !strings.Contains(records[0], "WARC-Type: warcinfo") {
+		t.Errorf("expected first record to be a warcinfo record, got %s", records[0])
+	}
+
+	if !strings.Contains(records[1], "WARC-Type: request") ||
+		!strings.Contains(records[1], "GET /page HTTP/1.1") ||
+		!strings.Contains(records[1], "WARC-Target-URI: "+ts.URL+"/page") {
+		t.Errorf("request record missing expected content: %s", records[1])
+	}
+
+	if !strings.Contains(records[2], "WARC-Type: response") ||
+		!strings.Contains(records[2], "HTTP/1.1 200 OK") ||
+		!strings.Contains(records[2], "<html><body>hi</body></html>") ||
+		!strings.Contains(records[2], "WARC-Concurrent-To:") {
+		t.Errorf("response record missing expected content: %s", records[2])
+	}
+}